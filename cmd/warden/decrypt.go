@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/julianstephens/warden/internal/crypto"
+	"github.com/julianstephens/warden/internal/repository"
+)
+
+// runDecrypt implements `warden decrypt`: open an envelope read from stdin
+// (or -in) with an identity file or a scrypt passphrase, writing the
+// recovered plaintext to stdout (or -out).
+func runDecrypt(args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	identityPath := fs.String("identity", "", "path to an identity file")
+	passphrase := fs.String("passphrase", "", "decrypt using a scrypt passphrase instead of an identity file")
+	maxWorkFactor := fs.Int("max-work-factor", 0, "reject scrypt stanzas above this work factor (0 = default)")
+	in := fs.String("in", "-", "input path, or - for stdin")
+	out := fs.String("out", "-", "output path, or - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	identities, err := resolveDecryptIdentities(*identityPath, *passphrase, *maxWorkFactor)
+	if err != nil {
+		return err
+	}
+
+	input, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := createOutput(*out)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	r, err := repository.DecryptFrom(input, identities)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(output, r)
+	return err
+}
+
+func resolveDecryptIdentities(identityPath, passphrase string, maxWorkFactor int) ([]crypto.EnvelopeIdentity, error) {
+	switch {
+	case passphrase != "":
+		return []crypto.EnvelopeIdentity{crypto.NewScryptIdentity(passphrase, maxWorkFactor)}, nil
+	case identityPath != "":
+		id, err := loadIdentity(identityPath)
+		if err != nil {
+			return nil, err
+		}
+		return []crypto.EnvelopeIdentity{id}, nil
+	default:
+		return nil, fmt.Errorf("decrypt requires -identity or -passphrase")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"io"
+
+	"github.com/julianstephens/warden/internal/crypto"
+	"github.com/julianstephens/warden/internal/repository"
+)
+
+// runEncrypt implements `warden encrypt`: seal stdin (or -in) to one or
+// more X25519 recipient files, or to a scrypt passphrase, writing the
+// envelope to stdout (or -out).
+func runEncrypt(args []string) error {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	var recipients recipientFiles
+	fs.Var(&recipients, "recipient", "path to a recipient file (repeatable)")
+	passphrase := fs.String("passphrase", "", "encrypt to a scrypt passphrase instead of recipient files")
+	workFactor := fs.Int("work-factor", 20, "scrypt work factor when -passphrase is set")
+	in := fs.String("in", "-", "input path, or - for stdin")
+	out := fs.String("out", "-", "output path, or - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	envelopeRecipients, err := resolveEncryptRecipients(recipients, *passphrase, *workFactor)
+	if err != nil {
+		return err
+	}
+
+	input, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := createOutput(*out)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	w, err := repository.EncryptTo(output, envelopeRecipients)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, input); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func resolveEncryptRecipients(recipients recipientFiles, passphrase string, workFactor int) ([]crypto.EnvelopeRecipient, error) {
+	if passphrase != "" {
+		r, err := crypto.NewScryptRecipient(passphrase, workFactor)
+		if err != nil {
+			return nil, err
+		}
+		return []crypto.EnvelopeRecipient{r}, nil
+	}
+	return recipients.load()
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// openInput opens path for reading, or stdin when path is "-".
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// createOutput creates or truncates path for writing, or stdout when path
+// is "-".
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
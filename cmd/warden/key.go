@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/julianstephens/warden/internal/crypto"
+	"github.com/julianstephens/warden/internal/repository"
+)
+
+// runKey dispatches `warden key <subcommand>`.
+func runKey(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: warden key <rekdf> [flags]")
+	}
+	switch args[0] {
+	case "rekdf":
+		return runKeyRekdf(args[1:])
+	default:
+		return fmt.Errorf("unknown key subcommand %q", args[0])
+	}
+}
+
+// runKeyRekdf implements `warden key rekdf`: calibrate fresh Argon2 params
+// for this machine and re-seal the repository config under them, without
+// touching its plaintext contents.
+func runKeyRekdf(args []string) error {
+	fs := flag.NewFlagSet("key rekdf", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to the repository config file")
+	password := fs.String("password", "", "repository passphrase")
+	target := fs.Duration("target", 0, "target single-derivation time (default 500ms)")
+	memoryMiB := fs.Int("memory-mb", 0, "memory budget in MiB (default 256)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *configPath == "" || *password == "" {
+		return fmt.Errorf("key rekdf requires -config and -password")
+	}
+
+	var memoryBudget int
+	if *memoryMiB > 0 {
+		memoryBudget = *memoryMiB * 1024 * 1024
+	}
+
+	newParams, err := crypto.CalibrateParams(*target, memoryBudget)
+	if err != nil {
+		return fmt.Errorf("calibrating new Argon2 params: %w", err)
+	}
+
+	repo := repository.Open(*configPath)
+	if err := repo.Rekey(*password, newParams); err != nil {
+		return fmt.Errorf("rekeying %s: %w", *configPath, err)
+	}
+
+	fmt.Printf("warden: rekeyed %s to T=%d M=%dKiB P=%d\n", *configPath, newParams.T, newParams.M, newParams.P)
+	return nil
+}
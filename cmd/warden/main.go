@@ -0,0 +1,38 @@
+// Command warden is the CLI front end for backup-mgr-cli repositories: it
+// encrypts and decrypts backup payloads to X25519 or scrypt recipients,
+// and manages the Argon2 parameters a repository's config is sealed under.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "encrypt":
+		err = runEncrypt(os.Args[2:])
+	case "decrypt":
+		err = runDecrypt(os.Args[2:])
+	case "key":
+		err = runKey(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warden:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: warden <encrypt|decrypt|key> [flags]")
+}
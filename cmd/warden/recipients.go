@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/julianstephens/warden/internal/crypto"
+)
+
+// recipientFiles collects repeated -recipient flag values naming paths to
+// recipient files, each holding one hex-encoded crypto.Recipient.
+type recipientFiles []string
+
+func (r *recipientFiles) String() string { return strings.Join(*r, ",") }
+
+func (r *recipientFiles) Set(path string) error {
+	*r = append(*r, path)
+	return nil
+}
+
+func (r recipientFiles) load() ([]crypto.EnvelopeRecipient, error) {
+	recipients := make([]crypto.EnvelopeRecipient, 0, len(r))
+	for _, path := range r {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading recipient file %s: %w", path, err)
+		}
+		recipient, err := crypto.ParseRecipient(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("parsing recipient file %s: %w", path, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// loadIdentity reads and parses the identity file at path.
+func loadIdentity(path string) (*crypto.Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading identity file %s: %w", path, err)
+	}
+	id, err := crypto.ParseIdentity(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("parsing identity file %s: %w", path, err)
+	}
+	return id, nil
+}
@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/alecthomas/units"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	defaultCalibrateTarget = 500 * time.Millisecond
+	defaultMemoryBudget    = 256 * int(units.MiB)
+	calibrateTolerance     = 0.10
+	calibrateMaxIterations = 24
+
+	// minCalibrateMemory is the lowest M (in KiB, per argon2.IDKey's units)
+	// calibrate will shrink to while hunting for a T=1 derivation that fits
+	// within target.
+	minCalibrateMemory = 8 * int(units.MiB) / int(units.KiB)
+)
+
+// CalibrateParams benchmarks argon2.IDKey on the local machine and returns
+// Params whose single-derivation time is within ~10% of target while using
+// no more than memoryBudget bytes of memory. target defaults to 500ms and
+// memoryBudget defaults to 256 MiB when zero. It returns an error rather
+// than out-of-spec Params if target can't be reached even after shrinking
+// memory down to a floor (e.g. on a very slow host), or if it fails to
+// converge within a bounded number of iterations.
+func CalibrateParams(target time.Duration, memoryBudget int) (Params, error) {
+	if target <= 0 {
+		target = defaultCalibrateTarget
+	}
+	if memoryBudget <= 0 {
+		memoryBudget = defaultMemoryBudget
+	}
+
+	return calibrate(target, memoryBudget, benchmarkArgon2)
+}
+
+// calibrate holds the search loop apart from CalibrateParams so tests can
+// pin a fake benchmark function instead of paying for real Argon2
+// derivations.
+func calibrate(target time.Duration, memoryBudget int, benchmark func(Params) (time.Duration, error)) (Params, error) {
+	params := Params{
+		T: 1,
+		M: memoryBudget / int(units.KiB),
+		P: DefaultParams.P,
+		L: DefaultParams.L,
+	}
+
+	for i := 0; i < calibrateMaxIterations; i++ {
+		d, err := benchmark(params)
+		if err != nil {
+			return Params{}, err
+		}
+		if d <= 0 {
+			return Params{}, fmt.Errorf("crypto: calibration measured non-positive duration")
+		}
+
+		if withinTolerance(d, target, calibrateTolerance) {
+			return params, nil
+		}
+
+		// Argon2's cost scales ~linearly with T for fixed M and P, so scale
+		// our current estimate by how far off we are and try again.
+		next := int(math.Round(float64(params.T) * float64(target) / float64(d)))
+		if next < 1 {
+			next = 1
+		}
+		if next == params.T {
+			// T can't move any further, yet we're still outside tolerance.
+			// If even T=1 overshoots target, raising T can never help;
+			// shrink M instead and keep searching down to a floor, rather
+			// than silently returning params whose derivation time
+			// violates the promised tolerance.
+			if params.T == 1 && d > target && params.M > minCalibrateMemory {
+				params.M /= 2
+				if params.M < minCalibrateMemory {
+					params.M = minCalibrateMemory
+				}
+				continue
+			}
+			return Params{}, fmt.Errorf("crypto: calibration stalled at T=%d, M=%dKiB with derivation time %s, outside %.0f%% of target %s", params.T, params.M, d, calibrateTolerance*100, target)
+		}
+		params.T = next
+	}
+
+	return Params{}, fmt.Errorf("crypto: calibration did not converge after %d iterations", calibrateMaxIterations)
+}
+
+func withinTolerance(d, target time.Duration, tolerance float64) bool {
+	diff := math.Abs(float64(d - target))
+	return diff <= float64(target)*tolerance
+}
+
+// benchmarkArgon2 measures one argon2.IDKey derivation under params using a
+// throwaway password and salt.
+func benchmarkArgon2(params Params) (time.Duration, error) {
+	salt := NewSalt()
+
+	start := time.Now()
+	argon2.IDKey([]byte("warden-calibration"), salt, uint32(params.T), uint32(params.M), uint8(params.P), uint32(params.L))
+	return time.Since(start), nil
+}
@@ -0,0 +1,79 @@
+package crypto
+
+import (
+	"testing"
+	"time"
+)
+
+// linearBenchmark fakes Argon2's ~linear T scaling for a fixed M and P so
+// calibrate's search loop can be exercised deterministically.
+func linearBenchmark(msPerT time.Duration) func(Params) (time.Duration, error) {
+	return func(p Params) (time.Duration, error) {
+		return time.Duration(p.T) * msPerT, nil
+	}
+}
+
+func TestCalibrateConvergesWithinTolerance(t *testing.T) {
+	target := 500 * time.Millisecond
+	params, err := calibrate(target, 256*1024*1024, linearBenchmark(37*time.Millisecond))
+	if err != nil {
+		t.Fatalf("calibrate: %v", err)
+	}
+	if params.T < 1 {
+		t.Fatalf("got T=%d, want >= 1", params.T)
+	}
+
+	got := time.Duration(params.T) * 37 * time.Millisecond
+	if !withinTolerance(got, target, calibrateTolerance) {
+		t.Fatalf("T=%d gives %s, not within %.0f%% of %s", params.T, got, calibrateTolerance*100, target)
+	}
+}
+
+func TestCalibrateErrorsWhenMemoryFloorStillOvershoots(t *testing.T) {
+	target := 10 * time.Millisecond
+	memoryBudget := 256 * 1024 * 1024
+
+	// Even T=1 takes far longer than target regardless of M, so calibrate
+	// cannot shrink its way into tolerance and must report this instead of
+	// returning out-of-spec params.
+	_, err := calibrate(target, memoryBudget, func(Params) (time.Duration, error) {
+		return 500 * time.Millisecond, nil
+	})
+	if err == nil {
+		t.Fatal("expected an error when T=1 always overshoots target, got nil")
+	}
+}
+
+func TestCalibrateShrinksMemoryThenSucceeds(t *testing.T) {
+	target := 10 * time.Millisecond
+	memoryBudget := 256 * 1024 * 1024
+
+	// T=1 overshoots until M has been halved a few times, at which point
+	// the fake benchmark reports a duration within tolerance.
+	calls := 0
+	params, err := calibrate(target, memoryBudget, func(p Params) (time.Duration, error) {
+		calls++
+		if p.M > memoryBudget/1024/8 {
+			return 50 * time.Millisecond, nil
+		}
+		return target, nil
+	})
+	if err != nil {
+		t.Fatalf("calibrate: %v", err)
+	}
+	if params.T != 1 {
+		t.Fatalf("got T=%d, want 1", params.T)
+	}
+	if calls < 2 {
+		t.Fatalf("expected calibrate to retry after shrinking M, got %d calls", calls)
+	}
+}
+
+func TestCalibrateRejectsNonPositiveDuration(t *testing.T) {
+	_, err := calibrate(500*time.Millisecond, 256*1024*1024, func(Params) (time.Duration, error) {
+		return 0, nil
+	})
+	if err == nil {
+		t.Fatal("expected an error for a non-positive measured duration, got nil")
+	}
+}
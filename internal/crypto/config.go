@@ -0,0 +1,136 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// configFileMode restricts saved config blobs to the owner: they round-trip
+// to a passphrase-derived key, so stray read access to the file is one less
+// layer of defense than intended.
+const configFileMode = 0o600
+
+const configMagic = "WRDNCFG\x01"
+
+// configHeaderSize is len(configMagic) + 4 uint32 Params fields + the salt.
+const configHeaderSize = len(configMagic) + 4*4 + saltSize
+
+// ErrInvalidConfig is returned when a config blob's header is missing,
+// truncated, or carries the wrong magic.
+var ErrInvalidConfig = errors.New("crypto: malformed config blob")
+
+// SealConfig derives an ID key from password under params with a freshly
+// generated salt, then encrypts plaintext with XChaCha20-Poly1305 into a
+// self-describing blob: magic "WRDNCFG\x01", the Argon2 params and salt
+// used to derive the key, then the ciphertext. The params and salt are
+// bound as additional authenticated data, so OpenConfig detects any
+// attempt to silently downgrade them. This mirrors NNCP's cfgenc design
+// and lets the repository be opened with only a passphrase and this blob.
+func SealConfig(params Params, password string, plaintext []byte) ([]byte, error) {
+	salt := NewSalt()
+
+	key, err := NewIDKey(params, password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := marshalConfigHeader(params, salt)
+
+	ciphertext, err := Encrypt(*key, plaintext, &header)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(header, ciphertext...), nil
+}
+
+// OpenConfig parses the header written by SealConfig, re-derives the ID
+// key from password using the embedded params and salt, and decrypts the
+// blob. It fails if the params or salt were altered since sealing.
+func OpenConfig(blob []byte, password string) ([]byte, error) {
+	params, salt, ciphertext, err := parseConfigHeader(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := NewIDKey(params, password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := blob[:configHeaderSize]
+	return Decrypt(*key, ciphertext, &header)
+}
+
+// SaveConfig seals plaintext under params and password with SealConfig and
+// writes the resulting blob to path, creating or truncating it with
+// owner-only permissions.
+func SaveConfig(path string, params Params, password string, plaintext []byte) error {
+	blob, err := SealConfig(params, password, plaintext)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, blob, configFileMode)
+}
+
+// LoadConfig reads the blob at path and opens it with OpenConfig, so a
+// repository can be unlocked from only a passphrase and this file.
+func LoadConfig(path string, password string) ([]byte, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return OpenConfig(blob, password)
+}
+
+// RekeyConfig re-derives the key for the config at path under newParams,
+// leaving its plaintext contents unchanged. This is the core operation
+// behind re-deriving a repository's ID key under freshly calibrated
+// Argon2 parameters: it loads the existing plaintext with the params
+// recorded in the file, then reseals it so future opens use newParams
+// instead.
+func RekeyConfig(path string, password string, newParams Params) error {
+	plaintext, err := LoadConfig(path, password)
+	if err != nil {
+		return err
+	}
+	return SaveConfig(path, newParams, password, plaintext)
+}
+
+func marshalConfigHeader(params Params, salt []byte) []byte {
+	header := make([]byte, 0, configHeaderSize)
+	header = append(header, configMagic...)
+
+	var field [4]byte
+	for _, v := range []int{params.T, params.M, params.P, params.L} {
+		binary.BigEndian.PutUint32(field[:], uint32(v))
+		header = append(header, field[:]...)
+	}
+
+	return append(header, salt...)
+}
+
+func parseConfigHeader(blob []byte) (params Params, salt []byte, ciphertext []byte, err error) {
+	if len(blob) < configHeaderSize {
+		err = fmt.Errorf("%w: too short", ErrInvalidConfig)
+		return
+	}
+	if string(blob[:len(configMagic)]) != configMagic {
+		err = fmt.Errorf("%w: bad magic", ErrInvalidConfig)
+		return
+	}
+
+	fields := blob[len(configMagic) : len(configMagic)+4*4]
+	params = Params{
+		T: int(binary.BigEndian.Uint32(fields[0:4])),
+		M: int(binary.BigEndian.Uint32(fields[4:8])),
+		P: int(binary.BigEndian.Uint32(fields[8:12])),
+		L: int(binary.BigEndian.Uint32(fields[12:16])),
+	}
+
+	salt = blob[len(configMagic)+4*4 : configHeaderSize]
+	ciphertext = blob[configHeaderSize:]
+	return
+}
@@ -0,0 +1,136 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testCalibratedParams(t *testing.T) Params {
+	t.Helper()
+	// A cheap fixed-cost Params for tests; calibration itself is covered in
+	// calibrate_test.go.
+	return Params{T: 1, M: 8 * 1024, P: 1, L: keySize}
+}
+
+func TestSealOpenConfigRoundTrip(t *testing.T) {
+	params := testCalibratedParams(t)
+	plaintext := []byte(`{"repo":"backup-mgr"}`)
+
+	blob, err := SealConfig(params, "xK9!mPq2&zR7$wL4#vN8@tY3", plaintext)
+	if err != nil {
+		t.Fatalf("SealConfig: %v", err)
+	}
+
+	got, err := OpenConfig(blob, "xK9!mPq2&zR7$wL4#vN8@tY3")
+	if err != nil {
+		t.Fatalf("OpenConfig: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, want %q", got, plaintext)
+	}
+}
+
+func TestOpenConfigRejectsWrongPassword(t *testing.T) {
+	params := testCalibratedParams(t)
+	blob, err := SealConfig(params, "xK9!mPq2&zR7$wL4#vN8@tY3", []byte("secret"))
+	if err != nil {
+		t.Fatalf("SealConfig: %v", err)
+	}
+
+	if _, err := OpenConfig(blob, "wrong-Pa55word!ZZZZ999"); err == nil {
+		t.Fatal("expected an error for the wrong password, got nil")
+	}
+}
+
+func TestOpenConfigDetectsParamDowngrade(t *testing.T) {
+	params := testCalibratedParams(t)
+	blob, err := SealConfig(params, "xK9!mPq2&zR7$wL4#vN8@tY3", []byte("secret"))
+	if err != nil {
+		t.Fatalf("SealConfig: %v", err)
+	}
+
+	// Flip the low bit of the authenticated L field (the last Params word
+	// before the salt): the AAD binds the whole header, so decryption must
+	// fail instead of silently accepting the altered params. Unlike T or M,
+	// perturbing L by one doesn't blow up derivation cost.
+	tampered := append([]byte{}, blob...)
+	lOffset := len(configMagic) + 4*4 - 1
+	tampered[lOffset] ^= 0x01
+
+	if _, err := OpenConfig(tampered, "xK9!mPq2&zR7$wL4#vN8@tY3"); err == nil {
+		t.Fatal("expected an error for a tampered params header, got nil")
+	}
+}
+
+func TestOpenConfigRejectsMalformedBlob(t *testing.T) {
+	if _, err := OpenConfig([]byte("too short"), "xK9!mPq2&zR7$wL4#vN8@tY3"); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("got err %v, want ErrInvalidConfig", err)
+	}
+
+	params := testCalibratedParams(t)
+	blob, err := SealConfig(params, "xK9!mPq2&zR7$wL4#vN8@tY3", []byte("secret"))
+	if err != nil {
+		t.Fatalf("SealConfig: %v", err)
+	}
+	badMagic := append([]byte{}, blob...)
+	badMagic[0] ^= 0xff
+	if _, err := OpenConfig(badMagic, "xK9!mPq2&zR7$wL4#vN8@tY3"); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("got err %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestSaveLoadConfigRoundTrip(t *testing.T) {
+	params := testCalibratedParams(t)
+	path := filepath.Join(t.TempDir(), "warden.cfg")
+	plaintext := []byte(`{"repo":"backup-mgr"}`)
+
+	if err := SaveConfig(path, params, "xK9!mPq2&zR7$wL4#vN8@tY3", plaintext); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+
+	got, err := LoadConfig(path, "xK9!mPq2&zR7$wL4#vN8@tY3")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, want %q", got, plaintext)
+	}
+}
+
+func TestRekeyConfigPreservesPlaintextUnderNewParams(t *testing.T) {
+	oldParams := testCalibratedParams(t)
+	path := filepath.Join(t.TempDir(), "warden.cfg")
+	plaintext := []byte(`{"repo":"backup-mgr"}`)
+
+	if err := SaveConfig(path, oldParams, "xK9!mPq2&zR7$wL4#vN8@tY3", plaintext); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+
+	newParams := Params{T: 2, M: 8 * 1024, P: 1, L: keySize}
+	if err := RekeyConfig(path, "xK9!mPq2&zR7$wL4#vN8@tY3", newParams); err != nil {
+		t.Fatalf("RekeyConfig: %v", err)
+	}
+
+	got, err := LoadConfig(path, "xK9!mPq2&zR7$wL4#vN8@tY3")
+	if err != nil {
+		t.Fatalf("LoadConfig after rekey: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, want %q", got, plaintext)
+	}
+
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	params, _, _, err := parseConfigHeader(blob)
+	if err != nil {
+		t.Fatalf("parseConfigHeader: %v", err)
+	}
+	if params.T != newParams.T {
+		t.Fatalf("got T=%d after rekey, want %d", params.T, newParams.T)
+	}
+}
@@ -0,0 +1,427 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	envelopeMagic      = "WRDNENV\x01"
+	x25519WrapInfo     = "warden/v1 x25519"
+	envelopeHeaderInfo = "warden/v1 header"
+	x25519KeySize      = 32
+)
+
+var (
+	ErrNoRecipients        = errors.New("crypto: envelope requires at least one recipient")
+	ErrNoMatchingIdentity  = errors.New("crypto: no identity could unwrap the envelope")
+	ErrEnvelopeHeaderMAC   = errors.New("crypto: envelope header authentication failed")
+	ErrInvalidEnvelope     = errors.New("crypto: malformed envelope header")
+	ErrMixedRecipientKinds = errors.New("crypto: envelope cannot mix scrypt with other recipient kinds")
+)
+
+// stanzaKind tags which recipient type produced a header stanza.
+type stanzaKind byte
+
+const (
+	stanzaX25519 stanzaKind = 0x01
+	stanzaScrypt stanzaKind = 0x02
+)
+
+// stanza is a single per-recipient header entry: a kind tag plus an
+// opaque, kind-specific payload carrying the wrapped file key.
+type stanza struct {
+	kind    stanzaKind
+	payload []byte
+}
+
+// EnvelopeRecipient wraps a file key into a header stanza for
+// NewEnvelopeWriter. Implemented by Recipient (X25519) and ScryptRecipient.
+type EnvelopeRecipient interface {
+	wrapStanza(fileKey []byte) (stanza, error)
+}
+
+// EnvelopeIdentity tries to unwrap a header stanza for OpenEnvelope.
+// Implemented by Identity (X25519) and ScryptIdentity.
+type EnvelopeIdentity interface {
+	unwrapStanza(s stanza) ([]byte, error)
+}
+
+// errStanzaKindMismatch signals an identity was tried against a stanza of a
+// different kind; OpenEnvelope treats this the same as a failed unwrap.
+var errStanzaKindMismatch = errors.New("crypto: stanza kind mismatch")
+
+// Identity is the private half of an X25519 keypair used to unwrap envelope
+// recipient stanzas.
+type Identity struct {
+	privateKey [x25519KeySize]byte
+	publicKey  [x25519KeySize]byte
+}
+
+// Recipient is the public half of an X25519 keypair that a file key can be
+// wrapped to without a shared password.
+type Recipient struct {
+	publicKey [x25519KeySize]byte
+}
+
+// GenerateIdentity creates a new random X25519 identity.
+func GenerateIdentity() (*Identity, error) {
+	sk, err := NewRandom(x25519KeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := curve25519.X25519(sk, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+
+	id := &Identity{}
+	copy(id.privateKey[:], sk)
+	copy(id.publicKey[:], pk)
+	return id, nil
+}
+
+// Recipient returns the public half of id, suitable for sharing with
+// whoever encrypts to this identity.
+func (id *Identity) Recipient() Recipient {
+	return Recipient{publicKey: id.publicKey}
+}
+
+// String encodes r as hex, the form a recipient file named by the
+// -recipient flag is expected to carry.
+func (r Recipient) String() string {
+	return hex.EncodeToString(r.publicKey[:])
+}
+
+// ParseRecipient decodes a Recipient from the hex form produced by String,
+// as read from a recipient file named by the -recipient flag.
+func ParseRecipient(s string) (Recipient, error) {
+	pk, err := decodeX25519Key(s)
+	if err != nil {
+		return Recipient{}, err
+	}
+	return Recipient{publicKey: pk}, nil
+}
+
+// String encodes id's private key as hex, the form an identity file is
+// expected to carry. Treat the result as key material: anyone who reads it
+// can unwrap envelopes addressed to id.
+func (id *Identity) String() string {
+	return hex.EncodeToString(id.privateKey[:])
+}
+
+// ParseIdentity decodes an Identity from the hex form produced by String,
+// as read from an identity file, and recomputes its public key.
+func ParseIdentity(s string) (*Identity, error) {
+	sk, err := decodeX25519Key(s)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := curve25519.X25519(sk[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+
+	id := &Identity{privateKey: sk}
+	copy(id.publicKey[:], pk)
+	return id, nil
+}
+
+func decodeX25519Key(s string) ([x25519KeySize]byte, error) {
+	var key [x25519KeySize]byte
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return key, fmt.Errorf("crypto: decoding x25519 key: %w", err)
+	}
+	if len(raw) != x25519KeySize {
+		return key, fmt.Errorf("%w: expected %d key bytes, got %d", ErrInvalidEnvelope, x25519KeySize, len(raw))
+	}
+	copy(key[:], raw)
+	return key, nil
+}
+
+func (r Recipient) wrapStanza(fileKey []byte) (stanza, error) {
+	ephSk, err := NewRandom(x25519KeySize)
+	if err != nil {
+		return stanza{}, err
+	}
+
+	ephPk, err := curve25519.X25519(ephSk, curve25519.Basepoint)
+	if err != nil {
+		return stanza{}, err
+	}
+
+	shared, err := curve25519.X25519(ephSk, r.publicKey[:])
+	if err != nil {
+		return stanza{}, err
+	}
+
+	wrapKey, err := deriveX25519WrapKey(shared, ephPk, r.publicKey[:])
+	if err != nil {
+		return stanza{}, err
+	}
+
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return stanza{}, err
+	}
+
+	wrapped := aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil)
+
+	payload := make([]byte, 0, x25519KeySize+len(wrapped))
+	payload = append(payload, ephPk...)
+	payload = append(payload, wrapped...)
+	return stanza{kind: stanzaX25519, payload: payload}, nil
+}
+
+func (id *Identity) unwrapStanza(s stanza) ([]byte, error) {
+	if s.kind != stanzaX25519 {
+		return nil, errStanzaKindMismatch
+	}
+	if len(s.payload) <= x25519KeySize {
+		return nil, ErrInvalidEnvelope
+	}
+
+	ephPk := s.payload[:x25519KeySize]
+	wrapped := s.payload[x25519KeySize:]
+
+	shared, err := curve25519.X25519(id.privateKey[:], ephPk)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapKey, err := deriveX25519WrapKey(shared, ephPk, id.publicKey[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), wrapped, nil)
+}
+
+// deriveX25519WrapKey runs HKDF-SHA256 over the X25519 shared secret bound
+// to both public keys, so the wrap key commits to the recipient and the
+// ephemeral key used for this stanza.
+func deriveX25519WrapKey(shared, ephPk, recipientPk []byte) ([]byte, error) {
+	ikm := append(append(append([]byte{}, shared...), ephPk...), recipientPk...)
+	return hkdfExpand(ikm, x25519WrapInfo, keySize)
+}
+
+func deriveHeaderKey(fileKey []byte) ([]byte, error) {
+	return hkdfExpand(fileKey, envelopeHeaderInfo, sha256.Size)
+}
+
+func hkdfExpand(secret []byte, info string, size int) ([]byte, error) {
+	h := hkdf.New(sha256.New, secret, nil, []byte(info))
+	out := make([]byte, size)
+	if _, err := io.ReadFull(h, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// maxEnvelopeRecipients bounds the recipient count read from an untrusted
+// header so a corrupted or malicious value can't force a huge allocation;
+// real backups are expected to target at most a few dozen recipients.
+const maxEnvelopeRecipients = 1024
+
+// maxStanzaPayload bounds a single stanza's payload length read from an
+// untrusted header; every stanza kind we support fits in well under this.
+const maxStanzaPayload = 4096
+
+func marshalStanzas(stanzas []stanza) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, uint32(len(stanzas)))
+	for _, s := range stanzas {
+		var lenBytes [2]byte
+		binary.BigEndian.PutUint16(lenBytes[:], uint16(len(s.payload)))
+		buf = append(buf, byte(s.kind))
+		buf = append(buf, lenBytes[:]...)
+		buf = append(buf, s.payload...)
+	}
+	return buf
+}
+
+// NewEnvelopeWriter wraps w with a hybrid envelope: a random file key is
+// generated and wrapped once per recipient (X25519 or scrypt), and the
+// resulting header is authenticated with an HMAC-SHA256 key derived from
+// the file key so recipients can detect header tampering. The plaintext
+// written through the returned writer is streamed with the
+// XChaCha20-Poly1305 framing from NewStreamWriter under the file key.
+//
+// Mixing ScryptRecipient with any other recipient kind is rejected: sharing
+// a passphrase would otherwise let anyone holding it read content meant to
+// be restricted to the stronger recipients.
+func NewEnvelopeWriter(w io.Writer, recipients []EnvelopeRecipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+	if mixesRecipientTypes(recipients) {
+		return nil, ErrMixedRecipientKinds
+	}
+
+	fileKey, err := NewRandom(keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	stanzas := make([]stanza, 0, len(recipients))
+	for _, r := range recipients {
+		s, err := r.wrapStanza(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		stanzas = append(stanzas, s)
+	}
+
+	header := append([]byte(envelopeMagic), marshalStanzas(stanzas)...)
+
+	headerKey, err := deriveHeaderKey(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, headerKey)
+	mac.Write(header)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(mac.Sum(nil)); err != nil {
+		return nil, err
+	}
+
+	return NewStreamWriter(w, Key{Data: fileKey}, nil)
+}
+
+// mixesRecipientTypes checks recipients up front, before any stanza is
+// wrapped, so a mixed-kind call fails fast instead of paying for an
+// expensive scrypt derivation only to be rejected afterward.
+func mixesRecipientTypes(recipients []EnvelopeRecipient) bool {
+	hasScrypt, hasOther := false, false
+	for _, r := range recipients {
+		if _, ok := r.(*ScryptRecipient); ok {
+			hasScrypt = true
+		} else {
+			hasOther = true
+		}
+	}
+	return hasScrypt && hasOther
+}
+
+func mixesRecipientKinds(stanzas []stanza) bool {
+	hasScrypt, hasOther := false, false
+	for _, s := range stanzas {
+		if s.kind == stanzaScrypt {
+			hasScrypt = true
+		} else {
+			hasOther = true
+		}
+	}
+	return hasScrypt && hasOther
+}
+
+// OpenEnvelope reads the header written by NewEnvelopeWriter, tries each of
+// identities against every recipient stanza until one unwraps the file key,
+// verifies the header HMAC, and returns a reader over the streamed
+// plaintext.
+func OpenEnvelope(r io.Reader, identities []EnvelopeIdentity) (io.Reader, error) {
+	magic := make([]byte, len(envelopeMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, fmt.Errorf("crypto: reading envelope magic: %w", err)
+	}
+	if string(magic) != envelopeMagic {
+		return nil, ErrInvalidEnvelope
+	}
+
+	var countBytes [4]byte
+	if _, err := io.ReadFull(r, countBytes[:]); err != nil {
+		return nil, fmt.Errorf("crypto: reading envelope recipient count: %w", err)
+	}
+	count := binary.BigEndian.Uint32(countBytes[:])
+	if count > maxEnvelopeRecipients {
+		return nil, ErrInvalidEnvelope
+	}
+
+	headerBuf := append([]byte{}, magic...)
+	headerBuf = append(headerBuf, countBytes[:]...)
+
+	stanzas := make([]stanza, count)
+	for i := range stanzas {
+		var head [3]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return nil, fmt.Errorf("crypto: reading envelope stanza %d header: %w", i, err)
+		}
+		payloadLen := binary.BigEndian.Uint16(head[1:])
+		if int(payloadLen) > maxStanzaPayload {
+			return nil, ErrInvalidEnvelope
+		}
+
+		payload := make([]byte, payloadLen)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, fmt.Errorf("crypto: reading envelope stanza %d payload: %w", i, err)
+		}
+
+		stanzas[i] = stanza{kind: stanzaKind(head[0]), payload: payload}
+		headerBuf = append(headerBuf, head[:]...)
+		headerBuf = append(headerBuf, payload...)
+	}
+	if mixesRecipientKinds(stanzas) {
+		return nil, ErrMixedRecipientKinds
+	}
+
+	gotMAC := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(r, gotMAC); err != nil {
+		return nil, fmt.Errorf("crypto: reading envelope header MAC: %w", err)
+	}
+
+	var fileKey []byte
+	var policyErr error
+	for _, id := range identities {
+		for _, s := range stanzas {
+			fk, err := id.unwrapStanza(s)
+			if err == nil {
+				fileKey = fk
+				break
+			}
+			if errors.Is(err, ErrScryptWorkFactorTooHigh) {
+				policyErr = err
+			}
+		}
+		if fileKey != nil {
+			break
+		}
+	}
+	if fileKey == nil {
+		if policyErr != nil {
+			return nil, policyErr
+		}
+		return nil, ErrNoMatchingIdentity
+	}
+
+	headerKey, err := deriveHeaderKey(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, headerKey)
+	mac.Write(headerBuf)
+	if !hmac.Equal(mac.Sum(nil), gotMAC) {
+		return nil, ErrEnvelopeHeaderMAC
+	}
+
+	return NewStreamReader(r, Key{Data: fileKey}, nil)
+}
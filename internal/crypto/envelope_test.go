@@ -0,0 +1,150 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestEnvelopeX25519RoundTrip(t *testing.T) {
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity: %v", err)
+	}
+
+	plaintext := []byte("back up the secrets")
+	var buf bytes.Buffer
+	w, err := NewEnvelopeWriter(&buf, []EnvelopeRecipient{id.Recipient()})
+	if err != nil {
+		t.Fatalf("NewEnvelopeWriter: %v", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r, err := OpenEnvelope(bytes.NewReader(buf.Bytes()), []EnvelopeIdentity{id})
+	if err != nil {
+		t.Fatalf("OpenEnvelope: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, want %q", got, plaintext)
+	}
+}
+
+func TestEnvelopeMultipleRecipients(t *testing.T) {
+	idA, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity: %v", err)
+	}
+	idB, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity: %v", err)
+	}
+	idC, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := NewEnvelopeWriter(&buf, []EnvelopeRecipient{idA.Recipient(), idB.Recipient()})
+	if err != nil {
+		t.Fatalf("NewEnvelopeWriter: %v", err)
+	}
+	if _, err := w.Write([]byte("shared payload")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// idB should unwrap the same envelope as idA.
+	r, err := OpenEnvelope(bytes.NewReader(buf.Bytes()), []EnvelopeIdentity{idB})
+	if err != nil {
+		t.Fatalf("OpenEnvelope with idB: %v", err)
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatalf("ReadAll with idB: %v", err)
+	}
+
+	// idC was never a recipient and must not be able to open it.
+	if _, err := OpenEnvelope(bytes.NewReader(buf.Bytes()), []EnvelopeIdentity{idC}); !errors.Is(err, ErrNoMatchingIdentity) {
+		t.Fatalf("got err %v, want ErrNoMatchingIdentity", err)
+	}
+}
+
+func TestEnvelopeRequiresRecipients(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := NewEnvelopeWriter(&buf, nil)
+	if !errors.Is(err, ErrNoRecipients) {
+		t.Fatalf("got err %v, want ErrNoRecipients", err)
+	}
+}
+
+func TestEnvelopeHeaderTamperDetected(t *testing.T) {
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := NewEnvelopeWriter(&buf, []EnvelopeRecipient{id.Recipient()})
+	if err != nil {
+		t.Fatalf("NewEnvelopeWriter: %v", err)
+	}
+	if _, err := w.Write([]byte("tamper me")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	tampered := buf.Bytes()
+	// Flip a byte inside the header, well before the HMAC and ciphertext.
+	tampered[len(envelopeMagic)] ^= 0x01
+
+	_, err = OpenEnvelope(bytes.NewReader(tampered), []EnvelopeIdentity{id})
+	if err == nil {
+		t.Fatal("expected an error for a tampered envelope header, got nil")
+	}
+}
+
+func TestRecipientIdentityStringRoundTrip(t *testing.T) {
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity: %v", err)
+	}
+
+	parsedID, err := ParseIdentity(id.String())
+	if err != nil {
+		t.Fatalf("ParseIdentity: %v", err)
+	}
+	if parsedID.publicKey != id.publicKey || parsedID.privateKey != id.privateKey {
+		t.Fatal("ParseIdentity did not round trip")
+	}
+
+	recipient := id.Recipient()
+	parsedRecipient, err := ParseRecipient(recipient.String())
+	if err != nil {
+		t.Fatalf("ParseRecipient: %v", err)
+	}
+	if parsedRecipient != recipient {
+		t.Fatal("ParseRecipient did not round trip")
+	}
+}
+
+func TestParseRecipientRejectsBadInput(t *testing.T) {
+	if _, err := ParseRecipient("not-hex"); err == nil {
+		t.Fatal("expected an error for non-hex input, got nil")
+	}
+	if _, err := ParseRecipient("aabb"); err == nil {
+		t.Fatal("expected an error for a short key, got nil")
+	}
+}
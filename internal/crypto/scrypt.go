@@ -0,0 +1,124 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	scryptSaltConst      = "warden/v1 scrypt"
+	scryptSaltRandomSize = 16
+	scryptR              = 8
+	scryptP              = 1
+
+	// maxScryptWorkFactor bounds N = 1<<workFactor well under scrypt.Key's
+	// own "parameters are too large" ceiling (N*r*128 must fit in memory),
+	// and far above any sane real-world cost.
+	maxScryptWorkFactor = 30
+
+	// DefaultMaxScryptWorkFactor bounds the scrypt work factor OpenEnvelope
+	// will honor from an untrusted header, preventing a malicious archive
+	// from forcing an excessive scrypt derivation on decrypt.
+	DefaultMaxScryptWorkFactor = 22
+)
+
+// ErrScryptWorkFactorTooHigh is returned when a scrypt stanza's work factor
+// exceeds the identity's configured maximum.
+var ErrScryptWorkFactorTooHigh = errors.New("crypto: scrypt work factor exceeds configured maximum")
+
+// ScryptRecipient wraps a file key under a passphrase with scrypt, for
+// callers who want a shareable passphrase instead of X25519 key files.
+// An envelope cannot mix a ScryptRecipient with any other recipient kind.
+type ScryptRecipient struct {
+	pass       string
+	workFactor int
+}
+
+// NewScryptRecipient returns a recipient that derives its wrap key from
+// pass with scrypt N = 1<<workFactor, r = 8, p = 1.
+func NewScryptRecipient(pass string, workFactor int) (*ScryptRecipient, error) {
+	if workFactor <= 0 || workFactor > maxScryptWorkFactor {
+		return nil, fmt.Errorf("crypto: invalid scrypt work factor %d", workFactor)
+	}
+	return &ScryptRecipient{pass: pass, workFactor: workFactor}, nil
+}
+
+func (r *ScryptRecipient) wrapStanza(fileKey []byte) (stanza, error) {
+	saltRandom, err := NewRandom(scryptSaltRandomSize)
+	if err != nil {
+		return stanza{}, err
+	}
+
+	wrapKey, err := deriveScryptWrapKey(r.pass, saltRandom, r.workFactor)
+	if err != nil {
+		return stanza{}, err
+	}
+
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return stanza{}, err
+	}
+
+	wrapped := aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil)
+
+	payload := make([]byte, 0, scryptSaltRandomSize+1+len(wrapped))
+	payload = append(payload, saltRandom...)
+	payload = append(payload, byte(r.workFactor))
+	payload = append(payload, wrapped...)
+	return stanza{kind: stanzaScrypt, payload: payload}, nil
+}
+
+// ScryptIdentity unwraps a ScryptRecipient stanza given the passphrase.
+// Its maxWorkFactor caps the cost OpenEnvelope will honor from an untrusted
+// header; NewScryptIdentity sets it, using DefaultMaxScryptWorkFactor for 0.
+type ScryptIdentity struct {
+	pass          string
+	maxWorkFactor int
+}
+
+// NewScryptIdentity returns an identity that unwraps ScryptRecipient
+// stanzas sealed under pass. maxWorkFactor caps the cost OpenEnvelope will
+// honor from an untrusted header; 0 uses DefaultMaxScryptWorkFactor.
+func NewScryptIdentity(pass string, maxWorkFactor int) *ScryptIdentity {
+	if maxWorkFactor <= 0 {
+		maxWorkFactor = DefaultMaxScryptWorkFactor
+	}
+	return &ScryptIdentity{pass: pass, maxWorkFactor: maxWorkFactor}
+}
+
+func (id *ScryptIdentity) unwrapStanza(s stanza) ([]byte, error) {
+	if s.kind != stanzaScrypt {
+		return nil, errStanzaKindMismatch
+	}
+	if len(s.payload) <= scryptSaltRandomSize+1 {
+		return nil, ErrInvalidEnvelope
+	}
+
+	saltRandom := s.payload[:scryptSaltRandomSize]
+	workFactor := int(s.payload[scryptSaltRandomSize])
+	wrapped := s.payload[scryptSaltRandomSize+1:]
+
+	if workFactor > id.maxWorkFactor {
+		return nil, ErrScryptWorkFactorTooHigh
+	}
+
+	wrapKey, err := deriveScryptWrapKey(id.pass, saltRandom, workFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), wrapped, nil)
+}
+
+func deriveScryptWrapKey(pass string, saltRandom []byte, workFactor int) ([]byte, error) {
+	salt := append([]byte(scryptSaltConst), saltRandom...)
+	return scrypt.Key([]byte(pass), salt, 1<<workFactor, scryptR, scryptP, keySize)
+}
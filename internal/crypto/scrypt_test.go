@@ -0,0 +1,119 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestEnvelopeScryptRoundTrip(t *testing.T) {
+	recipient, err := NewScryptRecipient("correct horse battery staple", 14)
+	if err != nil {
+		t.Fatalf("NewScryptRecipient: %v", err)
+	}
+
+	plaintext := []byte("passphrase-only backup")
+	var buf bytes.Buffer
+	w, err := NewEnvelopeWriter(&buf, []EnvelopeRecipient{recipient})
+	if err != nil {
+		t.Fatalf("NewEnvelopeWriter: %v", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	identity := NewScryptIdentity("correct horse battery staple", 0)
+	r, err := OpenEnvelope(bytes.NewReader(buf.Bytes()), []EnvelopeIdentity{identity})
+	if err != nil {
+		t.Fatalf("OpenEnvelope: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, want %q", got, plaintext)
+	}
+}
+
+func TestEnvelopeScryptWrongPassphrase(t *testing.T) {
+	recipient, err := NewScryptRecipient("correct horse battery staple", 14)
+	if err != nil {
+		t.Fatalf("NewScryptRecipient: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := NewEnvelopeWriter(&buf, []EnvelopeRecipient{recipient})
+	if err != nil {
+		t.Fatalf("NewEnvelopeWriter: %v", err)
+	}
+	if _, err := w.Write([]byte("data")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	identity := NewScryptIdentity("wrong passphrase", 0)
+	_, err = OpenEnvelope(bytes.NewReader(buf.Bytes()), []EnvelopeIdentity{identity})
+	if !errors.Is(err, ErrNoMatchingIdentity) {
+		t.Fatalf("got err %v, want ErrNoMatchingIdentity", err)
+	}
+}
+
+func TestNewScryptRecipientRejectsInvalidWorkFactor(t *testing.T) {
+	if _, err := NewScryptRecipient("pass", 0); err == nil {
+		t.Fatal("expected an error for work factor 0, got nil")
+	}
+	if _, err := NewScryptRecipient("pass", maxScryptWorkFactor+1); err == nil {
+		t.Fatal("expected an error for a work factor above the ceiling, got nil")
+	}
+}
+
+func TestOpenEnvelopeEnforcesMaxScryptWorkFactor(t *testing.T) {
+	// A work factor the identity is configured not to honor must be
+	// rejected before attempting the (expensive) derivation, rather than
+	// silently performing it.
+	recipient, err := NewScryptRecipient("pass", 12)
+	if err != nil {
+		t.Fatalf("NewScryptRecipient: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := NewEnvelopeWriter(&buf, []EnvelopeRecipient{recipient})
+	if err != nil {
+		t.Fatalf("NewEnvelopeWriter: %v", err)
+	}
+	if _, err := w.Write([]byte("data")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	identity := NewScryptIdentity("pass", 8)
+	_, err = OpenEnvelope(bytes.NewReader(buf.Bytes()), []EnvelopeIdentity{identity})
+	if !errors.Is(err, ErrScryptWorkFactorTooHigh) {
+		t.Fatalf("got err %v, want ErrScryptWorkFactorTooHigh", err)
+	}
+}
+
+func TestEnvelopeRejectsMixedRecipientKinds(t *testing.T) {
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity: %v", err)
+	}
+	scryptRecipient, err := NewScryptRecipient("pass", 14)
+	if err != nil {
+		t.Fatalf("NewScryptRecipient: %v", err)
+	}
+
+	_, err = NewEnvelopeWriter(&bytes.Buffer{}, []EnvelopeRecipient{id.Recipient(), scryptRecipient})
+	if !errors.Is(err, ErrMixedRecipientKinds) {
+		t.Fatalf("got err %v, want ErrMixedRecipientKinds", err)
+	}
+}
@@ -0,0 +1,264 @@
+package crypto
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// StreamChunkSize is the plaintext size of each frame in the streaming AEAD
+// format below, following the age/STREAM construction.
+const StreamChunkSize = 64 * 1024
+
+const (
+	streamCounterSize = 8
+	streamFlagSize    = 1
+	streamPrefixSize  = nonceSize - streamCounterSize - streamFlagSize
+)
+
+const (
+	streamFrameInterior byte = 0x00
+	streamFrameFinal    byte = 0x01
+)
+
+// ErrStreamTruncated is returned when a stream ends before a frame tagged as
+// final is seen.
+var ErrStreamTruncated = errors.New("crypto: stream truncated before final chunk")
+
+// streamNonce builds the per-frame XChaCha20-Poly1305 nonce from the
+// stream's random prefix, a monotonic frame counter, and a trailing byte
+// marking whether this is the final frame.
+func streamNonce(prefix []byte, counter uint64, final bool) []byte {
+	nonce := make([]byte, 0, nonceSize)
+	nonce = append(nonce, prefix...)
+	var ctr [streamCounterSize]byte
+	binary.BigEndian.PutUint64(ctr[:], counter)
+	nonce = append(nonce, ctr[:]...)
+	if final {
+		nonce = append(nonce, streamFrameFinal)
+	} else {
+		nonce = append(nonce, streamFrameInterior)
+	}
+	return nonce
+}
+
+type streamWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	aad     []byte
+	prefix  []byte
+	counter uint64
+	buf     []byte
+	closed  bool
+}
+
+// NewStreamWriter wraps w so that writes are chunked into StreamChunkSize
+// plaintext frames and each frame is sealed independently with
+// XChaCha20-Poly1305, following the age/STREAM construction. The stream's
+// random nonce prefix is written to w ahead of any ciphertext. Callers must
+// call Close to seal the final frame; writes after Close fail.
+func NewStreamWriter(w io.Writer, key Key, aad []byte) (io.WriteCloser, error) {
+	aead, err := chacha20poly1305.NewX(key.Data[:])
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := NewRandom(streamPrefixSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+
+	return &streamWriter{
+		w:      w,
+		aead:   aead,
+		aad:    aad,
+		prefix: prefix,
+		buf:    make([]byte, 0, StreamChunkSize),
+	}, nil
+}
+
+func (s *streamWriter) Write(p []byte) (n int, err error) {
+	if s.closed {
+		return 0, fmt.Errorf("crypto: write to closed stream")
+	}
+
+	for len(p) > 0 {
+		take := StreamChunkSize - len(s.buf)
+		if take > len(p) {
+			take = len(p)
+		}
+		s.buf = append(s.buf, p[:take]...)
+		p = p[take:]
+		n += take
+
+		if len(s.buf) == StreamChunkSize {
+			if err = s.flush(false); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (s *streamWriter) flush(final bool) error {
+	nonce := streamNonce(s.prefix, s.counter, final)
+	sealed := s.aead.Seal(nil, nonce, s.buf, s.aad)
+	s.buf = s.buf[:0]
+
+	if _, err := s.w.Write(sealed); err != nil {
+		return err
+	}
+
+	if !final {
+		if s.counter == math.MaxUint64 {
+			return fmt.Errorf("crypto: stream chunk counter overflow")
+		}
+		s.counter++
+	}
+	return nil
+}
+
+// Close seals any buffered plaintext as the final frame, tagged so readers
+// can detect truncation. It is safe to call Close more than once.
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.flush(true)
+}
+
+type streamReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	aad     []byte
+	prefix  []byte
+	counter uint64
+	plain   []byte
+	off     int
+	done    bool
+}
+
+// NewStreamReader wraps r to undo NewStreamWriter's framing, decrypting and
+// authenticating each frame in turn. If r ends before a frame tagged final
+// is seen, reading fails: with ErrStreamTruncated when r stops at a frame
+// boundary or leaves fewer bytes than an AEAD tag, and with an AEAD
+// authentication error when it stops partway through a longer frame.
+// Reordered or tampered frames also fail with AEAD authentication errors.
+func NewStreamReader(r io.Reader, key Key, aad []byte) (io.Reader, error) {
+	aead, err := chacha20poly1305.NewX(key.Data[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// Buffer size must exceed the largest frame so Peek(1) after a full
+	// frame read can observe whether more frames follow.
+	br := bufio.NewReaderSize(r, StreamChunkSize+aead.Overhead()+1)
+
+	prefix := make([]byte, streamPrefixSize)
+	if _, err := io.ReadFull(br, prefix); err != nil {
+		return nil, fmt.Errorf("crypto: reading stream prefix: %w", err)
+	}
+
+	return &streamReader{r: br, aead: aead, aad: aad, prefix: prefix}, nil
+}
+
+func (s *streamReader) Read(p []byte) (n int, err error) {
+	if s.off < len(s.plain) {
+		n = copy(p, s.plain[s.off:])
+		s.off += n
+		return n, nil
+	}
+	if s.done {
+		return 0, io.EOF
+	}
+
+	frame := make([]byte, StreamChunkSize+s.aead.Overhead())
+	read, rerr := io.ReadFull(s.r, frame)
+	switch {
+	case rerr == nil:
+		// full frame read; short of EOF, this still may or may not be last
+	case rerr == io.ErrUnexpectedEOF:
+		frame = frame[:read]
+	case rerr == io.EOF:
+		return 0, ErrStreamTruncated
+	default:
+		return 0, rerr
+	}
+
+	if len(frame) < s.aead.Overhead() {
+		return 0, ErrStreamTruncated
+	}
+
+	// A short frame can only be a genuine final chunk: interior chunks are
+	// always written at full size, so there is no ambiguity to resolve.
+	if len(frame) < StreamChunkSize+s.aead.Overhead() {
+		plain, derr := s.open(frame, true)
+		if derr != nil {
+			return 0, fmt.Errorf("crypto: decrypting stream chunk %d: %w", s.counter, derr)
+		}
+		return s.deliver(p, plain, true), nil
+	}
+
+	// A full-size frame with nothing after it is never a final frame written
+	// by NewStreamWriter, which seals every full buffer as an interior frame
+	// and so ends an exact multiple of the chunk size with an empty final
+	// frame. Finality is still settled by authentication, not by Peek:
+	// verify against the final nonce first, and if that fails, against the
+	// interior nonce. If the
+	// interior hypothesis authenticates, the real final chunk was dropped,
+	// so report truncation specifically rather than a generic AEAD error.
+	if _, peekErr := s.r.Peek(1); peekErr != nil {
+		if plain, derr := s.open(frame, true); derr == nil {
+			return s.deliver(p, plain, true), nil
+		}
+		if _, ierr := s.open(frame, false); ierr == nil {
+			return 0, ErrStreamTruncated
+		}
+		return 0, fmt.Errorf("crypto: decrypting stream chunk %d: %w", s.counter, errAuthFailed)
+	}
+
+	plain, derr := s.open(frame, false)
+	if derr != nil {
+		return 0, fmt.Errorf("crypto: decrypting stream chunk %d: %w", s.counter, derr)
+	}
+	return s.deliver(p, plain, false), nil
+}
+
+// errAuthFailed is reported when both the final and interior nonce
+// hypotheses fail to authenticate a full-size frame, i.e. the frame itself
+// is corrupt or tampered with rather than merely truncated.
+var errAuthFailed = errors.New("chacha20poly1305: message authentication failed")
+
+// open attempts to authenticate and decrypt frame under the nonce for
+// s.counter tagged with final. It never mutates frame, so callers may try
+// both hypotheses against the same bytes.
+func (s *streamReader) open(frame []byte, final bool) ([]byte, error) {
+	nonce := streamNonce(s.prefix, s.counter, final)
+	return s.aead.Open(nil, nonce, frame, s.aad)
+}
+
+// deliver stores plain as the buffered chunk, advances reader state, and
+// copies as much of it as fits into p.
+func (s *streamReader) deliver(p, plain []byte, final bool) int {
+	s.plain = plain
+	if final {
+		s.done = true
+	} else {
+		s.counter++
+	}
+
+	n := copy(p, s.plain)
+	s.off = n
+	return n
+}
@@ -0,0 +1,148 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+func testStreamKey(t *testing.T) Key {
+	t.Helper()
+	data, err := NewRandom(keySize)
+	if err != nil {
+		t.Fatalf("NewRandom: %v", err)
+	}
+	return Key{Data: data}
+}
+
+func sealStream(t *testing.T, key Key, aad []byte, plaintext []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewStreamWriter(&buf, key, aad)
+	if err != nil {
+		t.Fatalf("NewStreamWriter: %v", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	key := testStreamKey(t)
+	aad := []byte("repo-id")
+
+	for _, size := range []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3 * StreamChunkSize} {
+		plaintext := make([]byte, size)
+		for i := range plaintext {
+			plaintext[i] = byte(i)
+		}
+
+		sealed := sealStream(t, key, aad, plaintext)
+
+		r, err := NewStreamReader(bytes.NewReader(sealed), key, aad)
+		if err != nil {
+			t.Fatalf("size %d: NewStreamReader: %v", size, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("size %d: ReadAll: %v", size, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("size %d: round trip mismatch", size)
+		}
+	}
+}
+
+func TestStreamTruncatedMidChunk(t *testing.T) {
+	key := testStreamKey(t)
+	plaintext := make([]byte, 2*StreamChunkSize+10)
+	sealed := sealStream(t, key, nil, plaintext)
+
+	// Cut the ciphertext off partway through the second frame, well before
+	// the final frame is ever written.
+	truncated := sealed[:streamPrefixSize+StreamChunkSize+30]
+
+	r, err := NewStreamReader(bytes.NewReader(truncated), key, nil)
+	if err != nil {
+		t.Fatalf("NewStreamReader: %v", err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrStreamTruncated) {
+		t.Fatalf("got err %v, want ErrStreamTruncated", err)
+	}
+}
+
+func TestStreamTruncatedAfterFullInteriorChunk(t *testing.T) {
+	key := testStreamKey(t)
+	// Two full chunks of plaintext plus a short final chunk.
+	plaintext := make([]byte, 2*StreamChunkSize+10)
+	sealed := sealStream(t, key, nil, plaintext)
+
+	// Drop everything from the second frame onward, leaving exactly one
+	// full-size interior frame with nothing after it, so the reader has to
+	// settle by authentication that this frame is not the final one.
+	frameLen := StreamChunkSize + chacha20poly1305Overhead(t, key)
+	truncated := sealed[:streamPrefixSize+frameLen]
+
+	r, err := NewStreamReader(bytes.NewReader(truncated), key, nil)
+	if err != nil {
+		t.Fatalf("NewStreamReader: %v", err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrStreamTruncated) {
+		t.Fatalf("got err %v, want ErrStreamTruncated", err)
+	}
+}
+
+func TestStreamReorderedChunks(t *testing.T) {
+	key := testStreamKey(t)
+	plaintext := make([]byte, 2*StreamChunkSize+10)
+	sealed := sealStream(t, key, nil, plaintext)
+	overhead := chacha20poly1305Overhead(t, key)
+
+	first := sealed[streamPrefixSize : streamPrefixSize+StreamChunkSize+overhead]
+	rest := append([]byte{}, sealed[streamPrefixSize+StreamChunkSize+overhead:]...)
+
+	reordered := append([]byte{}, sealed[:streamPrefixSize]...)
+	reordered = append(reordered, rest...)
+	reordered = append(reordered, first...)
+
+	r, err := NewStreamReader(bytes.NewReader(reordered), key, nil)
+	if err != nil {
+		t.Fatalf("NewStreamReader: %v", err)
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatal("expected an authentication error for reordered chunks, got nil")
+	}
+}
+
+func TestStreamTamperedFinalChunk(t *testing.T) {
+	key := testStreamKey(t)
+	plaintext := []byte("short plaintext that fits in one final chunk")
+	sealed := sealStream(t, key, nil, plaintext)
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 0x01
+
+	r, err := NewStreamReader(bytes.NewReader(tampered), key, nil)
+	if err != nil {
+		t.Fatalf("NewStreamReader: %v", err)
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatal("expected an authentication error for a tampered final chunk, got nil")
+	}
+}
+
+func chacha20poly1305Overhead(t *testing.T, key Key) int {
+	t.Helper()
+	aead, err := chacha20poly1305.NewX(key.Data[:])
+	if err != nil {
+		t.Fatalf("chacha20poly1305.NewX: %v", err)
+	}
+	return aead.Overhead()
+}
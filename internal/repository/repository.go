@@ -0,0 +1,55 @@
+// Package repository wires the envelope and config primitives in
+// internal/crypto into a single backup repository: a config file on disk
+// that records how its ID key is derived, plus the recipients used to seal
+// and open backup payloads for a given session.
+package repository
+
+import (
+	"io"
+
+	"github.com/julianstephens/warden/internal/crypto"
+)
+
+// Repository is a single backup repository rooted at a config file on
+// disk. ConfigPath holds the sealed Params/salt/ciphertext blob written by
+// Init, read by Unlock, and rewritten in place by Rekey.
+type Repository struct {
+	ConfigPath string
+}
+
+// Open returns a Repository rooted at configPath. It does not touch the
+// filesystem; call Unlock or Init to actually read or create the config.
+func Open(configPath string) *Repository {
+	return &Repository{ConfigPath: configPath}
+}
+
+// Init seals plaintext (typically repository metadata) under params and
+// password and writes it to r.ConfigPath, creating the repository's config
+// file for the first time.
+func (r *Repository) Init(params crypto.Params, password string, plaintext []byte) error {
+	return crypto.SaveConfig(r.ConfigPath, params, password, plaintext)
+}
+
+// Unlock opens r.ConfigPath with password, returning the repository
+// metadata sealed by Init.
+func (r *Repository) Unlock(password string) ([]byte, error) {
+	return crypto.LoadConfig(r.ConfigPath, password)
+}
+
+// Rekey re-derives r's ID key under newParams without changing the
+// repository metadata. This is the operation behind the `warden key rekdf`
+// command: callers typically pass the result of crypto.CalibrateParams.
+func (r *Repository) Rekey(password string, newParams crypto.Params) error {
+	return crypto.RekeyConfig(r.ConfigPath, password, newParams)
+}
+
+// EncryptTo opens a sealed backup session over w addressed to recipients.
+func EncryptTo(w io.Writer, recipients []crypto.EnvelopeRecipient) (io.WriteCloser, error) {
+	return crypto.NewEnvelopeWriter(w, recipients)
+}
+
+// DecryptFrom opens a backup session sealed by EncryptTo, trying each of
+// identities in turn until one unwraps it.
+func DecryptFrom(src io.Reader, identities []crypto.EnvelopeIdentity) (io.Reader, error) {
+	return crypto.OpenEnvelope(src, identities)
+}